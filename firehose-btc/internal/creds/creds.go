@@ -0,0 +1,165 @@
+// Package creds builds the gRPC dial and call options needed to reach a
+// Firehose endpoint from a config-driven description of its credentials:
+// mutual TLS, a bearer token refreshed from a file, an OAuth2
+// client-credentials flow, or a plain API key.
+package creds
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/streamingfast/firehose-core/firehose/client"
+	"golang.org/x/oauth2/clientcredentials"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcoauth "google.golang.org/grpc/credentials/oauth"
+)
+
+// Config describes how to authenticate and secure the transport to a
+// Firehose endpoint. Zero value dials plaintext-over-TLS with no
+// credentials, matching the public endpoint's defaults.
+type Config struct {
+	// TLSCACert, when set, is a PEM file added to the root CA pool used
+	// to verify the server, for endpoints behind a private PKI.
+	TLSCACert string `toml:"tls_ca_cert"`
+	// TLSCert and TLSKey, when both set, are a PEM client certificate
+	// and key presented for mutual TLS.
+	TLSCert string `toml:"tls_cert"`
+	TLSKey  string `toml:"tls_key"`
+	// Insecure disables TLS entirely, for local/plaintext endpoints.
+	Insecure bool `toml:"insecure"`
+
+	// JWTFile, when set, is a file containing a bearer token sent with
+	// every call, re-read at most every JWTRefresh.
+	JWTFile string `toml:"jwt_file"`
+	// JWTRefresh bounds how often JWTFile is re-read. Defaults to 5m.
+	JWTRefresh time.Duration `toml:"jwt_refresh"`
+
+	// OAuth2TokenURL, when set, enables the OAuth2 client-credentials
+	// flow against that token endpoint.
+	OAuth2TokenURL     string   `toml:"oauth2_token_url"`
+	OAuth2ClientID     string   `toml:"oauth2_client_id"`
+	OAuth2ClientSecret string   `toml:"oauth2_client_secret"`
+	OAuth2Scopes       []string `toml:"oauth2_scopes"`
+
+	// APIKeyEnv names the environment variable holding a Firehose API
+	// key, used when none of JWTFile or OAuth2TokenURL are set.
+	APIKeyEnv string `toml:"api_key_env"`
+}
+
+// defaultJWTRefresh is used when Config.JWTRefresh is zero.
+const defaultJWTRefresh = 5 * time.Minute
+
+// LoadFile reads and parses a TOML credentials file.
+func LoadFile(path string) (Config, error) {
+	var cfg Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read credentials file %q: %w", path, err)
+	}
+
+	if err := toml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("failed to parse credentials file %q: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// DialOptions builds the transport and per-RPC gRPC options described by
+// cfg. At most one of the JWT, OAuth2 or API key credential sources is
+// used, in that order of precedence; none is used when Insecure is set or
+// none of them is configured.
+func (cfg Config) DialOptions() ([]grpc.DialOption, []grpc.CallOption, error) {
+	dialOpts, err := cfg.transportDialOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	callOpts, err := cfg.callOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return dialOpts, callOpts, nil
+}
+
+func (cfg Config) transportDialOptions() ([]grpc.DialOption, error) {
+	if cfg.Insecure {
+		return []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, nil
+	}
+
+	if cfg.TLSCACert == "" && cfg.TLSCert == "" && cfg.TLSKey == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if cfg.TLSCACert != "" {
+		pem, err := os.ReadFile(cfg.TLSCACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls_ca_cert %q: %w", cfg.TLSCACert, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls_ca_cert %q contains no valid PEM certificate", cfg.TLSCACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.TLSCert != "" || cfg.TLSKey != "" {
+		if cfg.TLSCert == "" || cfg.TLSKey == "" {
+			return nil, fmt.Errorf("tls_cert and tls_key must both be set for mutual TLS")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load mTLS client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}, nil
+}
+
+func (cfg Config) callOptions() ([]grpc.CallOption, error) {
+	// Per-RPC credentials all require transport security; sending them
+	// over an insecure connection fails at call time, so skip them
+	// entirely rather than let that happen.
+	if cfg.Insecure {
+		return nil, nil
+	}
+
+	switch {
+	case cfg.JWTFile != "":
+		refresh := cfg.JWTRefresh
+		if refresh <= 0 {
+			refresh = defaultJWTRefresh
+		}
+		return []grpc.CallOption{grpc.PerRPCCredentials(newFileBearerAuth(cfg.JWTFile, refresh))}, nil
+
+	case cfg.OAuth2TokenURL != "":
+		ts := (&clientcredentials.Config{
+			ClientID:     cfg.OAuth2ClientID,
+			ClientSecret: cfg.OAuth2ClientSecret,
+			TokenURL:     cfg.OAuth2TokenURL,
+			Scopes:       cfg.OAuth2Scopes,
+		}).TokenSource(context.Background())
+		return []grpc.CallOption{grpc.PerRPCCredentials(grpcoauth.TokenSource{TokenSource: ts})}, nil
+
+	case cfg.APIKeyEnv != "":
+		apiKey := os.Getenv(cfg.APIKeyEnv)
+		if apiKey == "" {
+			return nil, fmt.Errorf("%s env variable must be set", cfg.APIKeyEnv)
+		}
+		return []grpc.CallOption{grpc.PerRPCCredentials(&client.ApiKeyAuth{ApiKey: apiKey})}, nil
+
+	default:
+		return nil, nil
+	}
+}
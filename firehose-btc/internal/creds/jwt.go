@@ -0,0 +1,55 @@
+package creds
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileBearerAuth implements credentials.PerRPCCredentials, sending the
+// bearer token read from a file. The file is re-read at most once per
+// refresh interval, so a rotated token is picked up without restarting the
+// process.
+type fileBearerAuth struct {
+	path    string
+	refresh time.Duration
+
+	mu          sync.Mutex
+	cachedToken string
+	loadedAt    time.Time
+}
+
+func newFileBearerAuth(path string, refresh time.Duration) *fileBearerAuth {
+	return &fileBearerAuth{path: path, refresh: refresh}
+}
+
+func (a *fileBearerAuth) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	token, err := a.token()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+func (a *fileBearerAuth) RequireTransportSecurity() bool {
+	return true
+}
+
+func (a *fileBearerAuth) token() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.cachedToken == "" || time.Since(a.loadedAt) >= a.refresh {
+		data, err := os.ReadFile(a.path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read jwt_file %q: %w", a.path, err)
+		}
+		a.cachedToken = strings.TrimSpace(string(data))
+		a.loadedAt = time.Now()
+	}
+
+	return a.cachedToken, nil
+}
@@ -0,0 +1,37 @@
+package fetch
+
+import (
+	"math/rand"
+	"time"
+)
+
+// backoff computes exponentially increasing retry delays, capped at max and
+// then jittered by +/-20% to avoid thundering-herd reconnects, so a delay
+// ranges from 0.8*min to 1.2*max.
+type backoff struct {
+	min, max time.Duration
+	attempt  int
+}
+
+func newBackoff(min, max time.Duration) *backoff {
+	return &backoff{min: min, max: max}
+}
+
+// next returns the delay to wait before the next attempt and advances the
+// internal attempt counter.
+func (b *backoff) next() time.Duration {
+	d := b.min << b.attempt
+	if d <= 0 || d > b.max {
+		d = b.max
+	}
+	b.attempt++
+
+	jitter := time.Duration(float64(d) * (0.8 + 0.4*rand.Float64()))
+	return jitter
+}
+
+// reset clears the attempt counter, to be called after a successful
+// reconnect.
+func (b *backoff) reset() {
+	b.attempt = 0
+}
@@ -0,0 +1,51 @@
+// Package fetch wraps the Firehose Bitcoin gRPC client with the fetch and
+// streaming flows used by the firehose-btc command.
+package fetch
+
+import (
+	pbfirehose "github.com/streamingfast/pbgo/sf/firehose/v2"
+
+	"github.com/mostynb/go-grpc-compression/zstd"
+	"github.com/streamingfast/dgrpc"
+	"google.golang.org/grpc"
+
+	"firehose-btc/internal/creds"
+)
+
+// DefaultEndpoint is the public Firehose Bitcoin mainnet endpoint.
+const DefaultEndpoint = "mainnet.btc.streamingfast.io:443"
+
+// NewFetchClient dials endpoint, secured and authenticated as described by
+// cfg, and returns a client for the single-block Fetch service.
+func NewFetchClient(endpoint string, cfg creds.Config) (pbfirehose.FetchClient, func() error, []grpc.CallOption, error) {
+	conn, callOpts, err := dial(endpoint, cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return pbfirehose.NewFetchClient(conn), conn.Close, callOpts, nil
+}
+
+// NewStreamClient dials endpoint, secured and authenticated as described by
+// cfg, and returns a client for the Stream service.
+func NewStreamClient(endpoint string, cfg creds.Config) (pbfirehose.StreamClient, func() error, []grpc.CallOption, error) {
+	conn, callOpts, err := dial(endpoint, cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return pbfirehose.NewStreamClient(conn), conn.Close, callOpts, nil
+}
+
+func dial(endpoint string, cfg creds.Config) (*grpc.ClientConn, []grpc.CallOption, error) {
+	dialOpts, callOpts, err := cfg.DialOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := dgrpc.NewExternalClient(endpoint, dialOpts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	callOpts = append(callOpts, grpc.UseCompressor(zstd.Name))
+	return conn, callOpts, nil
+}
@@ -0,0 +1,57 @@
+package fetch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadCursor reads a previously persisted cursor from path. It returns an
+// empty string, without error, when path is empty or the file does not
+// exist yet.
+func LoadCursor(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read cursor file %q: %w", path, err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SaveCursor persists cursor to path, replacing its previous content. The
+// write is atomic: it writes to a temporary file in the same directory and
+// renames it over path, so a crash mid-write never leaves a truncated
+// cursor behind.
+func SaveCursor(path, cursor string) error {
+	if path == "" {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary cursor file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(cursor); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cursor file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close cursor file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to persist cursor file %q: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package fetch
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadCursorMissingFile(t *testing.T) {
+	cursor, err := LoadCursor(filepath.Join(t.TempDir(), "does-not-exist"))
+	if err != nil {
+		t.Fatalf("LoadCursor: %v", err)
+	}
+	if cursor != "" {
+		t.Fatalf("got cursor %q, want empty", cursor)
+	}
+}
+
+func TestLoadCursorEmptyPath(t *testing.T) {
+	cursor, err := LoadCursor("")
+	if err != nil {
+		t.Fatalf("LoadCursor: %v", err)
+	}
+	if cursor != "" {
+		t.Fatalf("got cursor %q, want empty", cursor)
+	}
+}
+
+func TestSaveCursorEmptyPath(t *testing.T) {
+	if err := SaveCursor("", "some-cursor"); err != nil {
+		t.Fatalf("SaveCursor: %v", err)
+	}
+}
+
+func TestSaveAndLoadCursorRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cursor")
+
+	if err := SaveCursor(path, "cursor-1"); err != nil {
+		t.Fatalf("SaveCursor: %v", err)
+	}
+
+	got, err := LoadCursor(path)
+	if err != nil {
+		t.Fatalf("LoadCursor: %v", err)
+	}
+	if got != "cursor-1" {
+		t.Fatalf("got cursor %q, want %q", got, "cursor-1")
+	}
+
+	// A second save must replace the previous content rather than append
+	// to it.
+	if err := SaveCursor(path, "cursor-2"); err != nil {
+		t.Fatalf("SaveCursor: %v", err)
+	}
+
+	got, err = LoadCursor(path)
+	if err != nil {
+		t.Fatalf("LoadCursor: %v", err)
+	}
+	if got != "cursor-2" {
+		t.Fatalf("got cursor %q, want %q", got, "cursor-2")
+	}
+}
+
+func TestSaveCursorLeavesNoTempFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cursor")
+
+	if err := SaveCursor(path, "cursor-1"); err != nil {
+		t.Fatalf("SaveCursor: %v", err)
+	}
+
+	entries, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	if len(entries) != 1 || entries[0] != path {
+		t.Fatalf("got directory entries %v, want only %q", entries, path)
+	}
+}
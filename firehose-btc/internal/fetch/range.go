@@ -0,0 +1,234 @@
+package fetch
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+
+	pbfirehose "github.com/streamingfast/pbgo/sf/firehose/v2"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+)
+
+// maxFetchAttempts bounds the attempts, the first one included,
+// fetchWithRetry makes for a single block before giving up.
+const maxFetchAttempts = 5
+
+// RangeSink receives blocks fetched by FetchRange, in strict ascending
+// height order.
+type RangeSink interface {
+	Write(ctx context.Context, height uint64, raw []byte) error
+	Close() error
+}
+
+// ResumableSink is implemented by sinks that can report whether they
+// already hold a given height, so FetchRange's Resume option can skip
+// re-fetching it.
+type ResumableSink interface {
+	RangeSink
+	Has(height uint64) (bool, error)
+}
+
+// RangeConfig configures a FetchRange call.
+type RangeConfig struct {
+	// Start and Stop bound the (inclusive) range of block heights to
+	// fetch.
+	Start uint64
+	Stop  uint64
+	// Workers is the number of goroutines concurrently fetching blocks,
+	// sharing the same gRPC connection. Values below 1 are treated as 1.
+	Workers int
+	// RatePerSecond, when positive, caps the number of requests each
+	// worker issues per second.
+	RatePerSecond float64
+	// Resume, when set and sink implements ResumableSink, skips heights
+	// the sink already has.
+	Resume bool
+}
+
+// FetchRange fetches every height in [cfg.Start, cfg.Stop] using
+// cfg.Workers concurrent goroutines sharing cli, retrying transient gRPC
+// errors with backoff, and writes the results to sink in strict ascending
+// height order regardless of the order responses arrive in. It returns as
+// soon as any block permanently fails to fetch or be written.
+func FetchRange(ctx context.Context, cli pbfirehose.FetchClient, callOpts []grpc.CallOption, cfg RangeConfig, sink RangeSink) error {
+	if cfg.Stop < cfg.Start {
+		return fmt.Errorf("invalid range: stop %d is before start %d", cfg.Stop, cfg.Start)
+	}
+
+	heights, err := pendingHeights(cfg, sink)
+	if err != nil {
+		return err
+	}
+	if len(heights) == 0 {
+		return nil
+	}
+
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan rangeJob)
+	results := make(chan rangeResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runRangeWorker(ctx, cli, callOpts, cfg.RatePerSecond, jobs, results)
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i, h := range heights {
+			select {
+			case jobs <- rangeJob{idx: i, height: h}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return drainInOrder(ctx, cancel, results, sink)
+}
+
+type rangeJob struct {
+	idx    int
+	height uint64
+}
+
+type rangeResult struct {
+	idx    int
+	height uint64
+	raw    []byte
+	err    error
+}
+
+func runRangeWorker(ctx context.Context, cli pbfirehose.FetchClient, callOpts []grpc.CallOption, ratePerSecond float64, jobs <-chan rangeJob, results chan<- rangeResult) {
+	var limiter *rate.Limiter
+	if ratePerSecond > 0 {
+		limiter = rate.NewLimiter(rate.Limit(ratePerSecond), 1)
+	}
+
+	for j := range jobs {
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				results <- rangeResult{idx: j.idx, height: j.height, err: err}
+				continue
+			}
+		}
+
+		raw, err := fetchWithRetry(ctx, cli, callOpts, j.height)
+		results <- rangeResult{idx: j.idx, height: j.height, raw: raw, err: err}
+	}
+}
+
+// fetchWithRetry fetches height, retrying transient gRPC errors with
+// backoff for at most maxFetchAttempts attempts in all.
+func fetchWithRetry(ctx context.Context, cli pbfirehose.FetchClient, callOpts []grpc.CallOption, height uint64) ([]byte, error) {
+	bo := newBackoff(minBackoff, maxBackoff)
+
+	var lastErr error
+	for attempt := 0; attempt < maxFetchAttempts; attempt++ {
+		resp, err := Block(ctx, cli, callOpts, height)
+		if err == nil {
+			return resp.Block.Value, nil
+		}
+
+		lastErr = err
+		if !isRetryableError(err) {
+			return nil, err
+		}
+		if err := sleepBackoff(ctx, bo); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("block %d: exhausted %d attempts: %w", height, maxFetchAttempts, lastErr)
+}
+
+// drainInOrder reads from results until it's closed, reordering them
+// through a min-heap keyed by submission index and writing to sink as soon
+// as the next expected result is available. On the first error, it cancels
+// ctx (stopping the workers and feeder), drains the rest of results so
+// those goroutines can exit, and returns the error.
+func drainInOrder(ctx context.Context, cancel context.CancelFunc, results <-chan rangeResult, sink RangeSink) error {
+	pending := &resultHeap{}
+	next := 0
+
+	fail := func(err error) error {
+		cancel()
+		for range results {
+		}
+		return err
+	}
+
+	for res := range results {
+		if res.err != nil {
+			return fail(fmt.Errorf("failed to fetch block %d: %w", res.height, res.err))
+		}
+
+		heap.Push(pending, res)
+		for pending.Len() > 0 && (*pending)[0].idx == next {
+			r := heap.Pop(pending).(rangeResult)
+			if err := sink.Write(ctx, r.height, r.raw); err != nil {
+				return fail(fmt.Errorf("failed to write block %d: %w", r.height, err))
+			}
+			next++
+		}
+	}
+
+	return nil
+}
+
+// pendingHeights returns the heights in [cfg.Start, cfg.Stop] that still
+// need to be fetched, skipping ones sink already has when cfg.Resume is
+// set and sink implements ResumableSink.
+func pendingHeights(cfg RangeConfig, sink RangeSink) ([]uint64, error) {
+	resumable, _ := sink.(ResumableSink)
+
+	heights := make([]uint64, 0, cfg.Stop-cfg.Start+1)
+	for h := cfg.Start; h <= cfg.Stop; h++ {
+		if cfg.Resume && resumable != nil {
+			has, err := resumable.Has(h)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check existing output for block %d: %w", h, err)
+			}
+			if has {
+				continue
+			}
+		}
+		heights = append(heights, h)
+	}
+
+	return heights, nil
+}
+
+// resultHeap orders rangeResults by submission index, the min-heap buffer
+// that lets FetchRange re-sequence out-of-order worker results.
+type resultHeap []rangeResult
+
+func (h resultHeap) Len() int           { return len(h) }
+func (h resultHeap) Less(i, j int) bool { return h[i].idx < h[j].idx }
+func (h resultHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *resultHeap) Push(x any)        { *h = append(*h, x.(rangeResult)) }
+func (h *resultHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
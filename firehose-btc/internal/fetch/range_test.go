@@ -0,0 +1,81 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+type memSink struct {
+	writes []uint64
+}
+
+func (s *memSink) Write(_ context.Context, height uint64, _ []byte) error {
+	s.writes = append(s.writes, height)
+	return nil
+}
+
+func (s *memSink) Close() error { return nil }
+
+// TestDrainInOrderReordersOutOfOrderResults feeds results in an order that
+// does not match their submission index and checks drainInOrder still
+// writes to the sink in strict ascending index order.
+func TestDrainInOrderReordersOutOfOrderResults(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan rangeResult, 5)
+	results <- rangeResult{idx: 2, height: 102}
+	results <- rangeResult{idx: 0, height: 100}
+	results <- rangeResult{idx: 4, height: 104}
+	results <- rangeResult{idx: 1, height: 101}
+	results <- rangeResult{idx: 3, height: 103}
+	close(results)
+
+	sink := &memSink{}
+	if err := drainInOrder(ctx, cancel, results, sink); err != nil {
+		t.Fatalf("drainInOrder: %v", err)
+	}
+
+	want := []uint64{100, 101, 102, 103, 104}
+	if !equalHeights(sink.writes, want) {
+		t.Fatalf("got writes %v, want %v", sink.writes, want)
+	}
+}
+
+// TestDrainInOrderStopsOnFirstError checks that an error for one result
+// aborts the drain and is returned, without writing later results that
+// were already buffered ahead of it.
+func TestDrainInOrderStopsOnFirstError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan rangeResult, 3)
+	results <- rangeResult{idx: 0, height: 100}
+	results <- rangeResult{idx: 1, height: 101, err: fmt.Errorf("boom")}
+	results <- rangeResult{idx: 2, height: 102}
+	close(results)
+
+	sink := &memSink{}
+	err := drainInOrder(ctx, cancel, results, sink)
+	if err == nil {
+		t.Fatal("drainInOrder: expected error, got nil")
+	}
+
+	want := []uint64{100}
+	if !equalHeights(sink.writes, want) {
+		t.Fatalf("got writes %v, want %v", sink.writes, want)
+	}
+}
+
+func equalHeights(got, want []uint64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
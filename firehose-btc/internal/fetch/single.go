@@ -0,0 +1,18 @@
+package fetch
+
+import (
+	"context"
+
+	pbfirehose "github.com/streamingfast/pbgo/sf/firehose/v2"
+
+	"google.golang.org/grpc"
+)
+
+// Block fetches a single block by its block number.
+func Block(ctx context.Context, cli pbfirehose.FetchClient, callOpts []grpc.CallOption, blockNum uint64) (*pbfirehose.SingleBlockResponse, error) {
+	return cli.Block(ctx, &pbfirehose.SingleBlockRequest{
+		Reference: &pbfirehose.SingleBlockRequest_BlockNumber_{
+			BlockNumber: &pbfirehose.SingleBlockRequest_BlockNumber{Num: blockNum},
+		},
+	}, callOpts...)
+}
@@ -0,0 +1,222 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	pbbtc "buf.build/gen/go/streamingfast/firehose-bitcoin/protocolbuffers/go/sf/bitcoin/type/v1"
+	pbfirehose "github.com/streamingfast/pbgo/sf/firehose/v2"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StepFunc handles one block delivered by StreamBlocks for a given fork
+// step. cursor is the opaque Firehose cursor positioned right after block.
+type StepFunc func(ctx context.Context, cursor string, block *pbbtc.Block) error
+
+// Handlers dispatches each streamed block to the callback matching its
+// ForkStep, so callers can roll back state on a Bitcoin reorg.
+//
+// A nil handler is simply skipped.
+type Handlers struct {
+	// OnNew is called for blocks newly appended to the chain (STEP_NEW).
+	OnNew StepFunc
+	// OnUndo is called for blocks excluded from the chain by a reorg
+	// (STEP_UNDO).
+	OnUndo StepFunc
+	// OnFinal is called for blocks that have become irreversible
+	// (STEP_FINAL).
+	OnFinal StepFunc
+}
+
+// Observer receives instrumentation events from StreamBlocks. Implementations
+// must be safe for concurrent use. A nil Observer is valid and simply
+// disables instrumentation.
+type Observer interface {
+	// ObserveBlock is called after a block has been successfully decoded
+	// and dispatched to its handler.
+	ObserveBlock(height int64, blockTime time.Time)
+	// ObserveFetchError is called whenever Recv or Blocks returns an
+	// error, labeled with the gRPC status code string.
+	ObserveFetchError(code string)
+	// ObserveFetchDuration is called with the latency of every Recv call,
+	// successful or not.
+	ObserveFetchDuration(d time.Duration)
+	// ObserveReconnect is called each time the stream is re-established
+	// after a retryable error.
+	ObserveReconnect()
+}
+
+// StreamConfig configures a StreamBlocks call.
+type StreamConfig struct {
+	// StartBlockNum is where the stream starts when no cursor is loaded
+	// from CursorFile. See pbfirehose.Request.StartBlockNum for the
+	// negative/relative-to-head semantics.
+	StartBlockNum int64
+	// StopBlockNum, when non-zero, stops the stream after that block.
+	StopBlockNum uint64
+	// FinalBlocksOnly, when set, only delivers STEP_FINAL blocks.
+	FinalBlocksOnly bool
+	// CursorFile, when set, is read on startup to resume a previous
+	// stream and rewritten after every processed block.
+	CursorFile string
+	// Observer, when set, is fed instrumentation events as the stream
+	// progresses.
+	Observer Observer
+}
+
+// minBackoff and maxBackoff are the first and largest base delays between
+// reconnect attempts; backoff jitters each delay by +/-20% around its base.
+const (
+	minBackoff = 1 * time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// StreamBlocks consumes cli.Blocks starting from the cursor persisted at
+// cfg.CursorFile (or cfg.StartBlockNum if there is none), dispatching every
+// received block to handlers and persisting the returned cursor to disk
+// after each one so the stream can resume exactly where it left off.
+//
+// On a retryable gRPC error (Unavailable, DeadlineExceeded, ResourceExhausted,
+// Aborted, Internal) the stream is re-established with an exponential
+// backoff, resuming from the last saved cursor. Any other error is returned
+// to the caller.
+func StreamBlocks(ctx context.Context, cli pbfirehose.StreamClient, callOpts []grpc.CallOption, cfg StreamConfig, handlers Handlers) error {
+	cursor, err := LoadCursor(cfg.CursorFile)
+	if err != nil {
+		return err
+	}
+
+	req := &pbfirehose.Request{
+		StartBlockNum:   cfg.StartBlockNum,
+		StopBlockNum:    cfg.StopBlockNum,
+		FinalBlocksOnly: cfg.FinalBlocksOnly,
+		Cursor:          cursor,
+	}
+
+	bo := newBackoff(minBackoff, maxBackoff)
+
+	for {
+		stream, err := cli.Blocks(ctx, req, callOpts...)
+		if err != nil {
+			observeError(cfg.Observer, err)
+			if !isRetryableError(err) {
+				return fmt.Errorf("failed to open blocks stream: %w", err)
+			}
+			if err := sleepBackoff(ctx, bo); err != nil {
+				return err
+			}
+			continue
+		}
+
+		reconnect, err := consumeStream(ctx, stream, req, handlers, cfg, bo)
+		if err != nil {
+			return err
+		}
+		if !reconnect {
+			return nil
+		}
+		if cfg.Observer != nil {
+			cfg.Observer.ObserveReconnect()
+		}
+	}
+}
+
+// consumeStream drains one stream until it ends (reconnect == false, err ==
+// nil), errors fatally (err != nil), or hits a retryable error, in which
+// case it backs off and returns reconnect == true so the caller can open a
+// fresh stream from req.Cursor.
+func consumeStream(ctx context.Context, stream pbfirehose.Stream_BlocksClient, req *pbfirehose.Request, handlers Handlers, cfg StreamConfig, bo *backoff) (reconnect bool, err error) {
+	for {
+		recvStart := time.Now()
+		resp, err := stream.Recv()
+		if cfg.Observer != nil {
+			cfg.Observer.ObserveFetchDuration(time.Since(recvStart))
+		}
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			observeError(cfg.Observer, err)
+			if !isRetryableError(err) {
+				return false, fmt.Errorf("blocks stream failed: %w", err)
+			}
+			if err := sleepBackoff(ctx, bo); err != nil {
+				return false, err
+			}
+			return true, nil
+		}
+
+		bo.reset()
+
+		var block pbbtc.Block
+		if err := resp.Block.UnmarshalTo(&block); err != nil {
+			return false, fmt.Errorf("failed to decode Bitcoin block: %w", err)
+		}
+
+		handler := handlerFor(handlers, resp.Step)
+		if handler != nil {
+			if err := handler(ctx, resp.Cursor, &block); err != nil {
+				return false, fmt.Errorf("handler failed for step %s: %w", resp.Step, err)
+			}
+		}
+
+		if cfg.Observer != nil {
+			cfg.Observer.ObserveBlock(block.Height, time.Unix(block.Time, 0))
+		}
+
+		req.Cursor = resp.Cursor
+		if err := SaveCursor(cfg.CursorFile, resp.Cursor); err != nil {
+			return false, err
+		}
+	}
+}
+
+func observeError(o Observer, err error) {
+	if o != nil {
+		o.ObserveFetchError(status.Code(err).String())
+	}
+}
+
+func handlerFor(handlers Handlers, step pbfirehose.ForkStep) StepFunc {
+	switch step {
+	case pbfirehose.ForkStep_STEP_NEW:
+		return handlers.OnNew
+	case pbfirehose.ForkStep_STEP_UNDO:
+		return handlers.OnUndo
+	case pbfirehose.ForkStep_STEP_FINAL:
+		return handlers.OnFinal
+	default:
+		return nil
+	}
+}
+
+// isRetryableError reports whether err is a transient gRPC error worth
+// reconnecting for, as opposed to one signalling a bad request or a
+// permanently closed stream.
+func isRetryableError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleepBackoff waits for the next backoff delay, or returns ctx.Err() if
+// ctx is cancelled first.
+func sleepBackoff(ctx context.Context, bo *backoff) error {
+	t := time.NewTimer(bo.next())
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
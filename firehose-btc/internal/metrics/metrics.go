@@ -0,0 +1,123 @@
+// Package metrics exposes Prometheus instrumentation and a /healthz probe
+// for the firehose-btc fetch loop.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	blocksFetched = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "firehose_btc_blocks_fetched_total",
+		Help: "Total number of blocks successfully fetched from Firehose.",
+	})
+
+	fetchErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "firehose_btc_fetch_errors_total",
+		Help: "Total number of fetch errors, labeled by gRPC status code.",
+	}, []string{"code"})
+
+	fetchDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "firehose_btc_fetch_duration_seconds",
+		Help:    "Latency of individual block fetches, in seconds.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	lastBlockHeight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "firehose_btc_last_block_height",
+		Help: "Height of the last block successfully fetched from Firehose.",
+	})
+
+	grpcReconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "firehose_btc_grpc_reconnects_total",
+		Help: "Total number of times the Firehose gRPC stream was re-established after an error.",
+	})
+)
+
+// Recorder tracks the state needed by the /healthz endpoint and feeds the
+// package's Prometheus collectors. It is safe for concurrent use.
+type Recorder struct {
+	maxBlockAge   time.Duration
+	lastBlockUnix atomic.Int64
+}
+
+// NewRecorder returns a Recorder whose Healthz handler reports unhealthy
+// once the last successfully fetched block is older than maxBlockAge.
+func NewRecorder(maxBlockAge time.Duration) *Recorder {
+	r := &Recorder{maxBlockAge: maxBlockAge}
+
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "firehose_btc_last_block_time_lag_seconds",
+		Help: "Age, in seconds, of the last block successfully fetched from Firehose.",
+	}, r.blockAgeSeconds)
+
+	return r
+}
+
+func (r *Recorder) blockAgeSeconds() float64 {
+	last := r.lastBlockUnix.Load()
+	if last == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(last, 0)).Seconds()
+}
+
+// ObserveBlock records a successfully fetched block.
+func (r *Recorder) ObserveBlock(height int64, blockTime time.Time) {
+	blocksFetched.Inc()
+	lastBlockHeight.Set(float64(height))
+	r.lastBlockUnix.Store(blockTime.Unix())
+}
+
+// ObserveFetchError records a failed fetch attempt, labeled with the gRPC
+// status code string (e.g. "Unavailable").
+func (r *Recorder) ObserveFetchError(code string) {
+	fetchErrors.WithLabelValues(code).Inc()
+}
+
+// ObserveFetchDuration records the latency of one fetch attempt, successful
+// or not.
+func (r *Recorder) ObserveFetchDuration(d time.Duration) {
+	fetchDuration.Observe(d.Seconds())
+}
+
+// ObserveReconnect records that the Firehose stream was re-established
+// after an error.
+func (r *Recorder) ObserveReconnect() {
+	grpcReconnects.Inc()
+}
+
+// Healthz reports 200 once at least one block has been fetched and the
+// last one isn't older than maxBlockAge, 503 otherwise.
+func (r *Recorder) Healthz(w http.ResponseWriter, _ *http.Request) {
+	last := r.lastBlockUnix.Load()
+	if last == 0 {
+		http.Error(w, "no block fetched yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	age := time.Since(time.Unix(last, 0))
+	if age > r.maxBlockAge {
+		http.Error(w, fmt.Sprintf("last block is %s old, exceeds threshold of %s", age, r.maxBlockAge), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+// NewServeMux returns the HTTP handler to bind -metrics-addr to: "/metrics"
+// for Prometheus scraping and "/healthz" for liveness probing.
+func NewServeMux(r *Recorder) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", r.Healthz)
+	return mux
+}
@@ -1,68 +1,285 @@
 package main
 
 import (
-        "context"
-        "encoding/hex"
-        "flag"
-        "fmt"
-        "log"
-        "os"
-        "time"
-
-        pbbtc "buf.build/gen/go/streamingfast/firehose-bitcoin/protocolbuffers/go/sf/bitcoin/type/v1"
-        pbfirehose "github.com/streamingfast/pbgo/sf/firehose/v2"
-
-        "github.com/mostynb/go-grpc-compression/zstd"
-        "github.com/streamingfast/firehose-core/firehose/client"
-        "google.golang.org/grpc"
-)
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	pbbtc "buf.build/gen/go/streamingfast/firehose-bitcoin/protocolbuffers/go/sf/bitcoin/type/v1"
 
-const FirehoseBTC = "mainnet.btc.streamingfast.io:443"
+	"firehose-btc/internal/creds"
+	"firehose-btc/internal/fetch"
+	"firehose-btc/internal/metrics"
+	"firehose-btc/internal/sink"
+)
 
 func main() {
-        blockNum := flag.Uint64("block", 840000, "")
-        parse := flag.Bool("parse", false, "")
-        flag.Parse()
-        apiKey := os.Getenv("SUBSTREAMS_API_KEY")
-        if apiKey == "" {
-                panic("SUBSTREAMS_API_KEY env variable must be set")
-        }
-
-        fhClient, closeFunc, callOpts, err := client.NewFirehoseFetchClient(FirehoseBTC, "", apiKey, false, false)
-        if err != nil {
-                log.Panicf("failed to create Firehose client: %s", err)
-        }
-        defer closeFunc()
-
-        // Optionally you can enable gRPC compression
-        callOpts = append(callOpts, grpc.UseCompressor(zstd.Name))
-
-        block, err := fhClient.Block(context.Background(), &pbfirehose.SingleBlockRequest{
-                // Request a block by its block number
-                Reference: &pbfirehose.SingleBlockRequest_BlockNumber_{
-                        BlockNumber: &pbfirehose.SingleBlockRequest_BlockNumber{Num: *blockNum},
-                },
-        }, callOpts...)
-        if err != nil {
-                log.Panicf("failed to fetch block: %s", err)
-        }
-
-
-        if *parse {
-                var btcBlock pbbtc.Block
-                err = block.Block.UnmarshalTo(&btcBlock)
-                if err != nil {
-                        log.Panicf("failed to decode to Bitcoin block: %s", err)
-                }
-
-                fmt.Printf("received block: %d, blocktime: %s, hash: %s, trxs: %d\n",
-                        btcBlock.Height,
-                        time.Unix(btcBlock.Time, 0),
-                        btcBlock.Hash,
-                        len(btcBlock.Tx),
-                )
-        }
-
-        blockData := hex.EncodeToString(block.Block.Value)
-        fmt.Printf("<block>:%s\n", blockData)
+	blockNum := flag.Uint64("block", 840000, "")
+	parse := flag.Bool("parse", false, "")
+	stream := flag.Bool("stream", false, "enable streaming mode instead of fetching a single block")
+	rangeMode := flag.Bool("range", false, "enable range mode, bulk-fetching [-start, -stop] with -workers concurrent workers")
+	start := flag.Int64("start", 0, "first block number to stream or fetch (streaming and range modes)")
+	stop := flag.Uint64("stop", 0, "last block number to stream or fetch, 0 means unbounded in streaming mode (streaming and range modes)")
+	cursorFile := flag.String("cursor-file", "", "path to persist the stream cursor, resumed from on restart (streaming mode only)")
+	finalBlocksOnly := flag.Bool("final-blocks-only", false, "only deliver STEP_FINAL blocks (streaming mode only)")
+	metricsAddr := flag.String("metrics-addr", "", "address to serve /metrics and /healthz on, e.g. :9102 (streaming mode only, disabled when empty)")
+	maxBlockAge := flag.Duration("max-block-age", 2*time.Hour, "/healthz reports unhealthy once the last fetched block is older than this (streaming mode only)")
+
+	endpoint := flag.String("endpoint", fetch.DefaultEndpoint, "Firehose gRPC endpoint to dial, host:port")
+	workers := flag.Int("workers", 4, "number of concurrent fetch workers (range mode only)")
+	rate := flag.Float64("rate", 0, "max requests per second, per worker, 0 disables rate limiting (range mode only)")
+	resume := flag.Bool("resume", false, "skip heights already present in the sink (range mode only, file and pb sinks only)")
+	sinkKind := flag.String("sink", "stdout", "output sink: stdout, file, ndjson, or pb (range mode only)")
+	sinkPath := flag.String("sink-path", "", "directory (file, pb sinks) or file path (ndjson sink) to write to (range mode only)")
+
+	credentialsFile := flag.String("credentials-file", "", "TOML file describing how to authenticate to the Firehose endpoint, see internal/creds.Config")
+	tlsCACert := flag.String("tls-ca-cert", "", "PEM root CA certificate to verify the Firehose endpoint against")
+	tlsCert := flag.String("tls-cert", "", "PEM client certificate for mutual TLS")
+	tlsKey := flag.String("tls-key", "", "PEM client key for mutual TLS")
+	insecure := flag.Bool("insecure", false, "disable TLS")
+	jwtFile := flag.String("jwt-file", "", "file containing a bearer token, re-read periodically")
+	jwtRefresh := flag.Duration("jwt-refresh", 0, "how often -jwt-file is re-read, defaults to 5m")
+	oauth2TokenURL := flag.String("oauth2-token-url", "", "OAuth2 client-credentials token endpoint")
+	oauth2ClientID := flag.String("oauth2-client-id", "", "")
+	oauth2ClientSecret := flag.String("oauth2-client-secret", "", "")
+	oauth2Scopes := flag.String("oauth2-scopes", "", "comma-separated OAuth2 scopes")
+	apiKeyEnv := flag.String("api-key-env", "SUBSTREAMS_API_KEY", "environment variable holding the Firehose API key, used when no jwt-file or oauth2-token-url is set")
+	flag.Parse()
+
+	credsCfg, err := loadCredentials(*credentialsFile, credentialsFlags{
+		tlsCACert:          *tlsCACert,
+		tlsCert:            *tlsCert,
+		tlsKey:             *tlsKey,
+		insecure:           *insecure,
+		jwtFile:            *jwtFile,
+		jwtRefresh:         *jwtRefresh,
+		oauth2TokenURL:     *oauth2TokenURL,
+		oauth2ClientID:     *oauth2ClientID,
+		oauth2ClientSecret: *oauth2ClientSecret,
+		oauth2Scopes:       *oauth2Scopes,
+		apiKeyEnv:          *apiKeyEnv,
+	})
+	if err != nil {
+		log.Panicf("failed to load credentials: %s", err)
+	}
+
+	switch {
+	case *rangeMode:
+		if *start < 0 {
+			log.Panicf("-start must be non-negative in range mode")
+		}
+		runRange(*endpoint, credsCfg, rangeFlags{
+			start:    uint64(*start),
+			stop:     *stop,
+			workers:  *workers,
+			rate:     *rate,
+			resume:   *resume,
+			sinkKind: *sinkKind,
+			sinkPath: *sinkPath,
+		})
+
+	case *stream:
+		runStream(*endpoint, credsCfg, streamFlags{
+			start:           *start,
+			stop:            *stop,
+			cursorFile:      *cursorFile,
+			finalBlocksOnly: *finalBlocksOnly,
+			metricsAddr:     *metricsAddr,
+			maxBlockAge:     *maxBlockAge,
+		})
+
+	default:
+		runSingleBlock(*endpoint, credsCfg, *blockNum, *parse)
+	}
+}
+
+type credentialsFlags struct {
+	tlsCACert          string
+	tlsCert            string
+	tlsKey             string
+	insecure           bool
+	jwtFile            string
+	jwtRefresh         time.Duration
+	oauth2TokenURL     string
+	oauth2ClientID     string
+	oauth2ClientSecret string
+	oauth2Scopes       string
+	apiKeyEnv          string
+}
+
+// loadCredentials builds a creds.Config from credentialsFile if set,
+// otherwise from the individual flags.
+func loadCredentials(credentialsFile string, flags credentialsFlags) (creds.Config, error) {
+	if credentialsFile != "" {
+		return creds.LoadFile(credentialsFile)
+	}
+
+	var scopes []string
+	if flags.oauth2Scopes != "" {
+		scopes = strings.Split(flags.oauth2Scopes, ",")
+	}
+
+	return creds.Config{
+		TLSCACert:          flags.tlsCACert,
+		TLSCert:            flags.tlsCert,
+		TLSKey:             flags.tlsKey,
+		Insecure:           flags.insecure,
+		JWTFile:            flags.jwtFile,
+		JWTRefresh:         flags.jwtRefresh,
+		OAuth2TokenURL:     flags.oauth2TokenURL,
+		OAuth2ClientID:     flags.oauth2ClientID,
+		OAuth2ClientSecret: flags.oauth2ClientSecret,
+		OAuth2Scopes:       scopes,
+		APIKeyEnv:          flags.apiKeyEnv,
+	}, nil
+}
+
+func runSingleBlock(endpoint string, credsCfg creds.Config, blockNum uint64, parse bool) {
+	fhClient, closeFunc, callOpts, err := fetch.NewFetchClient(endpoint, credsCfg)
+	if err != nil {
+		log.Panicf("failed to create Firehose client: %s", err)
+	}
+	defer closeFunc()
+
+	block, err := fetch.Block(context.Background(), fhClient, callOpts, blockNum)
+	if err != nil {
+		log.Panicf("failed to fetch block: %s", err)
+	}
+
+	if parse {
+		var btcBlock pbbtc.Block
+		err = block.Block.UnmarshalTo(&btcBlock)
+		if err != nil {
+			log.Panicf("failed to decode to Bitcoin block: %s", err)
+		}
+
+		fmt.Printf("received block: %d, blocktime: %s, hash: %s, trxs: %d\n",
+			btcBlock.Height,
+			time.Unix(btcBlock.Time, 0),
+			btcBlock.Hash,
+			len(btcBlock.Tx),
+		)
+	}
+
+	blockData := hex.EncodeToString(block.Block.Value)
+	fmt.Printf("<block>:%s\n", blockData)
+}
+
+type streamFlags struct {
+	start           int64
+	stop            uint64
+	cursorFile      string
+	finalBlocksOnly bool
+	metricsAddr     string
+	maxBlockAge     time.Duration
+}
+
+func runStream(endpoint string, credsCfg creds.Config, flags streamFlags) {
+	fhClient, closeFunc, callOpts, err := fetch.NewStreamClient(endpoint, credsCfg)
+	if err != nil {
+		log.Panicf("failed to create Firehose client: %s", err)
+	}
+	defer closeFunc()
+
+	recorder := metrics.NewRecorder(flags.maxBlockAge)
+	if flags.metricsAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(flags.metricsAddr, metrics.NewServeMux(recorder)); err != nil {
+				log.Panicf("metrics server failed: %s", err)
+			}
+		}()
+	}
+
+	cfg := fetch.StreamConfig{
+		StartBlockNum:   flags.start,
+		StopBlockNum:    flags.stop,
+		FinalBlocksOnly: flags.finalBlocksOnly,
+		CursorFile:      flags.cursorFile,
+		Observer:        recorder,
+	}
+
+	handlers := fetch.Handlers{
+		OnNew: func(_ context.Context, cursor string, block *pbbtc.Block) error {
+			fmt.Printf("new block: %d, blocktime: %s, hash: %s, trxs: %d, cursor: %s\n",
+				block.Height, time.Unix(block.Time, 0), block.Hash, len(block.Tx), cursor)
+			return nil
+		},
+		OnUndo: func(_ context.Context, cursor string, block *pbbtc.Block) error {
+			fmt.Printf("reorg: undoing block: %d, hash: %s, cursor: %s\n", block.Height, block.Hash, cursor)
+			return nil
+		},
+		OnFinal: func(_ context.Context, cursor string, block *pbbtc.Block) error {
+			fmt.Printf("final block: %d, hash: %s, cursor: %s\n", block.Height, block.Hash, cursor)
+			return nil
+		},
+	}
+
+	if err := fetch.StreamBlocks(context.Background(), fhClient, callOpts, cfg, handlers); err != nil {
+		log.Panicf("stream failed: %s", err)
+	}
+}
+
+type rangeFlags struct {
+	start    uint64
+	stop     uint64
+	workers  int
+	rate     float64
+	resume   bool
+	sinkKind string
+	sinkPath string
+}
+
+func runRange(endpoint string, credsCfg creds.Config, flags rangeFlags) {
+	fhClient, closeFunc, callOpts, err := fetch.NewFetchClient(endpoint, credsCfg)
+	if err != nil {
+		log.Panicf("failed to create Firehose client: %s", err)
+	}
+	defer closeFunc()
+
+	out, err := newSink(flags.sinkKind, flags.sinkPath)
+	if err != nil {
+		log.Panicf("failed to create sink: %s", err)
+	}
+	defer out.Close()
+
+	if flags.resume {
+		if _, ok := out.(fetch.ResumableSink); !ok {
+			log.Printf("warning: -resume has no effect with -sink=%s, which cannot report already-fetched heights", flags.sinkKind)
+		}
+	}
+
+	cfg := fetch.RangeConfig{
+		Start:         flags.start,
+		Stop:          flags.stop,
+		Workers:       flags.workers,
+		RatePerSecond: flags.rate,
+		Resume:        flags.resume,
+	}
+
+	if err := fetch.FetchRange(context.Background(), fhClient, callOpts, cfg, out); err != nil {
+		log.Panicf("range fetch failed: %s", err)
+	}
+}
+
+// newSink builds the RangeSink named by kind. path is a directory for the
+// file and pb sinks, or a file path for the ndjson sink; it is ignored by
+// the stdout sink.
+func newSink(kind, path string) (fetch.RangeSink, error) {
+	switch kind {
+	case "stdout":
+		return sink.Stdout(), nil
+	case "file":
+		return sink.FileDir(path)
+	case "pb":
+		return sink.PBDir(path)
+	case "ndjson":
+		return sink.NDJSON(path)
+	default:
+		return nil, fmt.Errorf("unknown sink %q, must be one of: stdout, file, ndjson, pb", kind)
+	}
 }